@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BenchResult summarizes one workload run against a demo endpoint.
+type BenchResult struct {
+	Endpoint string  `json:"endpoint"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	P50Ms    float64 `json:"p50_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+}
+
+// runWorkload fires concurrency workers at targetURL for the given
+// duration and returns latency percentiles and the error count.
+func runWorkload(client *http.Client, targetURL string, concurrency int, duration time.Duration) BenchResult {
+	deadline := time.Now().Add(duration)
+
+	var (
+		mu      sync.Mutex
+		samples []time.Duration
+		errs    int64
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				start := time.Now()
+				resp, err := client.Get(targetURL)
+				elapsed := time.Since(start)
+				if err != nil {
+					atomic.AddInt64(&errs, 1)
+					continue
+				}
+				resp.Body.Close()
+				if resp.StatusCode >= 400 {
+					atomic.AddInt64(&errs, 1)
+					continue
+				}
+
+				mu.Lock()
+				samples = append(samples, elapsed)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	// samples holds only successful requests, so len(samples)+errs is the
+	// true request count and error-response latencies never reach the
+	// percentiles below.
+	return BenchResult{
+		Endpoint: targetURL,
+		Requests: int64(len(samples)) + errs,
+		Errors:   errs,
+		P50Ms:    percentileMs(samples, 0.50),
+		P95Ms:    percentileMs(samples, 0.95),
+		P99Ms:    percentileMs(samples, 0.99),
+	}
+}
+
+// percentileMs returns the p-th percentile of sorted samples, in
+// milliseconds.
+func percentileMs(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return float64(sorted[idx].Microseconds()) / 1000
+}
+
+// benchHandler runs a short load test against /optimized-work-mem and
+// /low-work-mem in-process and reports latency percentiles, so the
+// work_mem comparison is reproducible without an external tool like ab.
+// ?concurrency and ?duration (a Go duration string, e.g. "2s") override the
+// defaults.
+func benchHandler(baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		concurrency := 10
+		if v := r.URL.Query().Get("concurrency"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				concurrency = n
+			}
+		}
+
+		duration := 2 * time.Second
+		if v := r.URL.Query().Get("duration"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil {
+				duration = d
+			}
+		}
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		results := []BenchResult{
+			runWorkload(client, baseURL+"/optimized-work-mem", concurrency, duration),
+			runWorkload(client, baseURL+"/low-work-mem", concurrency, duration),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			log.Printf("encode bench results: %v", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "work_mem_demo_request_duration_seconds",
+	Help:    "Latency of the work_mem demo endpoints, by endpoint and outcome.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"endpoint", "outcome"})
+
+var (
+	poolAcquireCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "work_mem_demo_pool_acquire_count",
+		Help: "Cumulative number of successful connection acquires from the pgx pool.",
+	})
+	poolAcquireDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "work_mem_demo_pool_acquire_duration_seconds",
+		Help: "Cumulative time spent waiting for a connection acquire from the pgx pool.",
+	})
+	poolIdleConns = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "work_mem_demo_pool_idle_conns",
+		Help: "Number of idle connections currently in the pgx pool.",
+	})
+	poolTotalConns = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "work_mem_demo_pool_total_conns",
+		Help: "Total number of connections currently in the pgx pool.",
+	})
+	poolEmptyAcquireCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "work_mem_demo_pool_empty_acquire_count",
+		Help: "Cumulative number of acquires that had to wait because no connection was idle.",
+	})
+)
+
+// statusRecorder captures the status code a handler writes, so instrument
+// can label the observed latency with an outcome.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// instrument wraps next to record its latency and outcome under name in
+// requestDuration, so /metrics can show the pool saturation that slow
+// low-work-mem queries cause without any extra instrumentation at the call
+// site.
+func instrument(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next(rec, r)
+
+		outcome := "ok"
+		if rec.status >= 400 {
+			outcome = "error"
+		}
+		requestDuration.WithLabelValues(name, outcome).Observe(time.Since(start).Seconds())
+	}
+}
+
+// recordPoolStats copies the current pgxpool.Stat() snapshot into the pool
+// gauges above. pgxpool has no native Prometheus collector, so something
+// has to bridge Stat() into one; this is called right before every
+// /metrics scrape.
+func recordPoolStats(pool *pgxpool.Pool) {
+	stat := pool.Stat()
+	poolAcquireCount.Set(float64(stat.AcquireCount()))
+	poolAcquireDuration.Set(stat.AcquireDuration().Seconds())
+	poolIdleConns.Set(float64(stat.IdleConns()))
+	poolTotalConns.Set(float64(stat.TotalConns()))
+	poolEmptyAcquireCount.Set(float64(stat.EmptyAcquireCount()))
+}
+
+// metricsHandler refreshes the pool gauges and then serves the standard
+// promhttp handler.
+func metricsHandler(pool *pgxpool.Pool) http.HandlerFunc {
+	promHandler := promhttp.Handler()
+	return func(w http.ResponseWriter, r *http.Request) {
+		recordPoolStats(pool)
+		promHandler.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// pgStatSnapshot is one row of pg_stat_statements for a normalized query,
+// taken before and after a handler invocation so the difference between the
+// two can be logged as that invocation's cost.
+type pgStatSnapshot struct {
+	Calls           int64
+	TotalExecTimeMS float64
+	Rows            int64
+	SharedBlksHit   int64
+	SharedBlksRead  int64
+	TempBlksRead    int64
+	TempBlksWritten int64
+}
+
+// delta returns the difference between before and after, representing what
+// one handler invocation cost according to pg_stat_statements.
+func (before pgStatSnapshot) delta(after pgStatSnapshot) pgStatSnapshot {
+	return pgStatSnapshot{
+		Calls:           after.Calls - before.Calls,
+		TotalExecTimeMS: after.TotalExecTimeMS - before.TotalExecTimeMS,
+		Rows:            after.Rows - before.Rows,
+		SharedBlksHit:   after.SharedBlksHit - before.SharedBlksHit,
+		SharedBlksRead:  after.SharedBlksRead - before.SharedBlksRead,
+		TempBlksRead:    after.TempBlksRead - before.TempBlksRead,
+		TempBlksWritten: after.TempBlksWritten - before.TempBlksWritten,
+	}
+}
+
+// ensurePgStatStatements creates the pg_stat_statements extension if it is
+// not already present. It requires pg_stat_statements to already be in
+// shared_preload_libraries; if it isn't, this returns an error and callers
+// should log it and carry on without deltas rather than treat it as fatal.
+func ensurePgStatStatements(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS pg_stat_statements"); err != nil {
+		return fmt.Errorf("ensure pg_stat_statements: %w", err)
+	}
+	// CREATE EXTENSION succeeds even without the preload; reading the view
+	// is what fails in that case.
+	if _, err := pool.Exec(ctx, "SELECT 1 FROM pg_stat_statements LIMIT 1"); err != nil {
+		return fmt.Errorf("read pg_stat_statements: %w", err)
+	}
+	return nil
+}
+
+// resolveQueryID looks up the pg_stat_statements queryid for the normalized
+// query whose text starts with prefix, excluding EXPLAIN-wrapped statements
+// so a /explain call against the same SQL text isn't picked up instead. It
+// is meant to be called once at startup to pin the row withStatsDelta reads
+// on every request — pg_stat_statements exposes no other stable handle for
+// "the query behind this prepared statement name".
+func resolveQueryID(ctx context.Context, pool *pgxpool.Pool, prefix string) (int64, error) {
+	const sql = `
+		SELECT queryid
+		FROM pg_stat_statements
+		WHERE query LIKE $1 AND query NOT LIKE 'EXPLAIN%'
+		ORDER BY queryid
+		LIMIT 1;
+	`
+
+	var queryID int64
+	if err := pool.QueryRow(ctx, sql, prefix).Scan(&queryID); err != nil {
+		return 0, fmt.Errorf("resolve queryid for %q: %w", prefix, err)
+	}
+	return queryID, nil
+}
+
+// snapshotStatement returns the current cumulative pg_stat_statements
+// counters for queryID. It returns the zero snapshot, not an error, if the
+// query has no matching row yet.
+func snapshotStatement(ctx context.Context, pool *pgxpool.Pool, queryID int64) (pgStatSnapshot, error) {
+	const sql = `
+		SELECT calls, total_exec_time, rows,
+		       shared_blks_hit, shared_blks_read, temp_blks_read, temp_blks_written
+		FROM pg_stat_statements
+		WHERE queryid = $1;
+	`
+
+	var s pgStatSnapshot
+	err := pool.QueryRow(ctx, sql, queryID).Scan(
+		&s.Calls, &s.TotalExecTimeMS, &s.Rows,
+		&s.SharedBlksHit, &s.SharedBlksRead, &s.TempBlksRead, &s.TempBlksWritten,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return pgStatSnapshot{}, nil
+	}
+	if err != nil {
+		return pgStatSnapshot{}, fmt.Errorf("snapshot pg_stat_statements: %w", err)
+	}
+	return s, nil
+}
+
+// statsWrapper confirms pg_stat_statements is available and resolves the
+// queryid topPlayersQueryLike names, then returns a middleware factory that
+// wraps handlers with withStatsDelta against that pinned queryid. If any
+// step fails, it logs why and returns a middleware that passes requests
+// through unwrapped — stats deltas are a diagnostic add-on the demo should
+// still run without.
+func statsWrapper(ctx context.Context, pool *pgxpool.Pool) func(name string, next http.HandlerFunc) http.HandlerFunc {
+	passthrough := func(name string, next http.HandlerFunc) http.HandlerFunc { return next }
+
+	if err := ensurePgStatStatements(ctx, pool); err != nil {
+		log.Printf("pg_stat_statements unavailable, request deltas will be skipped: %v", err)
+		return passthrough
+	}
+	if _, err := TopPlayers(ctx, pool, 1); err != nil {
+		log.Printf("pg_stat_statements warm-up query failed, request deltas will be skipped: %v", err)
+		return passthrough
+	}
+	queryID, err := resolveQueryID(ctx, pool, topPlayersQueryLike)
+	if err != nil {
+		log.Printf("pg_stat_statements queryid lookup failed, request deltas will be skipped: %v", err)
+		return passthrough
+	}
+
+	return func(name string, next http.HandlerFunc) http.HandlerFunc {
+		return withStatsDelta(pool, name, queryID, next)
+	}
+}
+
+// withStatsDelta wraps next to snapshot pg_stat_statements for queryID
+// before and after the request, logging the difference. A non-zero
+// temp_blks_written in that delta is the signature of a work_mem spill to
+// disk, turning an otherwise invisible aggregate into a per-request line.
+//
+// pg_stat_statements' counters for queryID are shared across every
+// connection currently running that query, not scoped to one request, so
+// the logged delta is only exact when a single invocation runs at a time.
+// Under concurrent load (e.g. /bench with concurrency > 1) it folds in
+// whatever other in-flight executions of the same query advanced the
+// counters between the snapshots, and should be read as an upper bound on
+// that request's contribution rather than an isolated measurement. Run
+// with ?concurrency=1 when exact per-request attribution matters.
+func withStatsDelta(pool *pgxpool.Pool, name string, queryID int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		before, err := snapshotStatement(r.Context(), pool, queryID)
+		if err != nil {
+			log.Printf("pg_stat_statements snapshot before %s: %v", name, err)
+			next(w, r)
+			return
+		}
+
+		next(w, r)
+
+		after, err := snapshotStatement(r.Context(), pool, queryID)
+		if err != nil {
+			log.Printf("pg_stat_statements snapshot after %s: %v", name, err)
+			return
+		}
+
+		d := before.delta(after)
+		log.Printf("pg_stat_statements delta endpoint=%s calls=%d total_exec_time_ms=%.3f rows=%d shared_blks_hit=%d shared_blks_read=%d temp_blks_read=%d temp_blks_written=%d",
+			name, d.Calls, d.TotalExecTimeMS, d.Rows, d.SharedBlksHit, d.SharedBlksRead, d.TempBlksRead, d.TempBlksWritten)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PlayerScore is one row of the top_players_query result: a player and
+// their combined goals plus assists.
+type PlayerScore struct {
+	PlayerID   int64
+	TotalScore int64
+}
+
+const topPlayersQueryName = "top_players_query"
+
+// topPlayersQueryLike is a LIKE pattern matching topPlayersQuerySQL's text
+// in pg_stat_statements, which tracks queries by SQL text rather than by
+// pgx's prepared statement name. It is only used once, by resolveQueryID at
+// startup, to pin the exact pg_stat_statements row withStatsDelta reads —
+// resolveQueryID additionally excludes EXPLAIN-wrapped statements, since
+// /explain runs this same SQL text and would otherwise match too.
+const topPlayersQueryLike = "%SELECT p.player_id%"
+
+const topPlayersQuerySQL = `
+	SELECT p.player_id, SUM(ps.goals + ps.assists) AS total_score
+	FROM player_stats ps
+	JOIN players p ON ps.player_id = p.player_id
+	GROUP BY p.player_id
+	ORDER BY total_score DESC
+	LIMIT $1;
+`
+
+// preparedQueries lists the named queries registerPreparedStatements
+// prepares on every pool connection. Add an entry here to make a query
+// available as a prepared statement to pool-backed and transaction-backed
+// callers alike.
+var preparedQueries = map[string]string{
+	topPlayersQueryName: topPlayersQuerySQL,
+}
+
+// registerPreparedStatements is installed as pgxpool.Config.AfterConnect so
+// every connection the pool opens has preparedQueries ready before it
+// serves a request, instead of handlers preparing statements ad hoc.
+func registerPreparedStatements(ctx context.Context, conn *pgx.Conn) error {
+	for name, sql := range preparedQueries {
+		if _, err := conn.Prepare(ctx, name, sql); err != nil {
+			return fmt.Errorf("prepare %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx, so typed query
+// wrappers like TopPlayers work whether called against a bare pool or a
+// transaction opened by withSession.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// TopPlayers runs the prepared top_players_query statement and scans the
+// result into PlayerScore structs, so callers measure plan behavior under
+// realistic row materialization instead of discarding results with Exec.
+func TopPlayers(ctx context.Context, q querier, limit int) ([]PlayerScore, error) {
+	rows, err := q.Query(ctx, topPlayersQueryName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query top players: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []PlayerScore
+	for rows.Next() {
+		var s PlayerScore
+		if err := rows.Scan(&s.PlayerID, &s.TotalScore); err != nil {
+			return nil, fmt.Errorf("scan player score: %w", err)
+		}
+		scores = append(scores, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate player scores: %w", err)
+	}
+	return scores, nil
+}
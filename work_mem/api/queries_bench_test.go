@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// benchPool connects to POSTGRES_URL and sets work_mem on every pool
+// connection as a startup parameter. It
+// skips the benchmark if POSTGRES_URL is not set, since these are
+// integration benchmarks that need a live database to compare prepared vs
+// unprepared execution.
+func benchPool(b *testing.B, workMem string) *pgxpool.Pool {
+	b.Helper()
+
+	dbURL := os.Getenv("POSTGRES_URL")
+	if dbURL == "" {
+		b.Skip("POSTGRES_URL not set, skipping")
+	}
+
+	cfg, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		b.Fatalf("parse POSTGRES_URL: %s", err)
+	}
+	cfg.AfterConnect = registerPreparedStatements
+	cfg.ConnConfig.RuntimeParams["work_mem"] = workMem
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		b.Fatalf("connect: %s", err)
+	}
+	b.Cleanup(pool.Close)
+
+	var got string
+	if err := pool.QueryRow(context.Background(), "SHOW work_mem").Scan(&got); err != nil || got != workMem {
+		b.Fatalf("set work_mem: got %q, want %q (err %v)", got, workMem, err)
+	}
+
+	return pool
+}
+
+func benchmarkTopPlayersPrepared(b *testing.B, workMem string) {
+	pool := benchPool(b, workMem)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := TopPlayers(ctx, pool, 2000); err != nil {
+			b.Fatalf("top players: %s", err)
+		}
+	}
+}
+
+// benchmarkTopPlayersUnprepared forces the simple query protocol so pgx
+// neither prepares nor caches the statement, mirroring how the original
+// fmt.Sprintf-built queries in this package used to run.
+func benchmarkTopPlayersUnprepared(b *testing.B, workMem string) {
+	pool := benchPool(b, workMem)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rows, err := pool.Query(ctx, topPlayersQuerySQL, pgx.QueryExecModeSimpleProtocol, 2000)
+		if err != nil {
+			b.Fatalf("query: %s", err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			b.Fatalf("rows: %s", err)
+		}
+	}
+}
+
+func BenchmarkTopPlayersPrepared_64kB(b *testing.B)   { benchmarkTopPlayersPrepared(b, "64kB") }
+func BenchmarkTopPlayersPrepared_4MB(b *testing.B)    { benchmarkTopPlayersPrepared(b, "4MB") }
+func BenchmarkTopPlayersUnprepared_64kB(b *testing.B) { benchmarkTopPlayersUnprepared(b, "64kB") }
+func BenchmarkTopPlayersUnprepared_4MB(b *testing.B)  { benchmarkTopPlayersUnprepared(b, "4MB") }
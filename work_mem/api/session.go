@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// sessionParamSpec whitelists one SET LOCAL parameter: the header clients may
+// use to override it, and a regex its value must match before it is ever
+// interpolated into SQL.
+type sessionParamSpec struct {
+	header string
+	valid  *regexp.Regexp
+}
+
+var memorySizeRe = regexp.MustCompile(`^\d+(kB|MB|GB)$`)
+var durationRe = regexp.MustCompile(`^\d+(ms|s|min|h|d)$`)
+var onOffRe = regexp.MustCompile(`^(on|off)$`)
+
+// allowedSessionParams is the whitelist of Postgres session settings the
+// /optimized-work-mem, /low-work-mem and related demo endpoints are allowed
+// to tune per request. Anything not listed here is rejected by withSession.
+var allowedSessionParams = map[string]sessionParamSpec{
+	"work_mem":                            {header: "X-PG-Work-Mem", valid: memorySizeRe},
+	"statement_timeout":                   {header: "X-PG-Statement-Timeout", valid: durationRe},
+	"lock_timeout":                        {header: "X-PG-Lock-Timeout", valid: durationRe},
+	"idle_in_transaction_session_timeout": {header: "X-PG-Idle-In-Transaction-Session-Timeout", valid: durationRe},
+	"enable_hashagg":                      {header: "X-PG-Enable-Hashagg", valid: onOffRe},
+	"jit":                                 {header: "X-PG-Jit", valid: onOffRe},
+}
+
+// SessionOption sets a server-side default for a whitelisted session
+// parameter. Defaults can still be overridden per request by the matching
+// header in allowedSessionParams, as long as the override also validates.
+type SessionOption func(params map[string]string)
+
+// WithParam sets name to value unless a request supplies the parameter's
+// whitelisted header, in which case the header wins. name must be a key in
+// allowedSessionParams or withSession rejects the request.
+func WithParam(name, value string) SessionOption {
+	return func(params map[string]string) {
+		params[name] = value
+	}
+}
+
+// sessionHandler receives the request and the transaction withSession opened
+// and configured, so the handler body can run queries against it without
+// re-deriving a connection. It is not given the http.ResponseWriter: the
+// response status is decided by withSession after the transaction commits,
+// so a commit failure is still reported as an error.
+type sessionHandler func(r *http.Request, tx pgx.Tx) error
+
+// withSession returns middleware that acquires a connection from pool,
+// begins a transaction, applies opts and any whitelisted header overrides as
+// SET LOCAL statements, then hands the transaction to next. The transaction
+// is committed if next returns nil, and rolled back otherwise; the response
+// status is only written once that outcome is known.
+func withSession(pool *pgxpool.Pool, opts ...SessionOption) func(sessionHandler) http.HandlerFunc {
+	return func(next sessionHandler) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			params := map[string]string{}
+			for _, opt := range opts {
+				opt(params)
+			}
+			if err := applyHeaderOverrides(params, r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			tx, err := pool.Begin(ctx)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("begin tx: %v", err), http.StatusInternalServerError)
+				return
+			}
+			defer tx.Rollback(ctx)
+
+			if err := applySessionParams(ctx, tx, params); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if err := next(r, tx); err != nil {
+				http.Error(w, fmt.Sprintf("query error: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			if err := tx.Commit(ctx); err != nil {
+				http.Error(w, fmt.Sprintf("commit: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+}
+
+// applyHeaderOverrides copies per-request overrides from whitelisted headers
+// into params, validating each value against its parameter's regex. Headers
+// for parameters not in allowedSessionParams are ignored.
+func applyHeaderOverrides(params map[string]string, r *http.Request) error {
+	for name, spec := range allowedSessionParams {
+		value := r.Header.Get(spec.header)
+		if value == "" {
+			continue
+		}
+		if !spec.valid.MatchString(value) {
+			return fmt.Errorf("invalid value %q for %s", value, spec.header)
+		}
+		params[name] = value
+	}
+	return nil
+}
+
+// applySessionParams runs SET LOCAL for every entry in params, rejecting any
+// name that is not in allowedSessionParams and any value that fails its
+// validator. Values are quoted as SQL string literals since SET LOCAL does
+// not accept query parameters.
+func applySessionParams(ctx context.Context, tx pgx.Tx, params map[string]string) error {
+	for name, value := range params {
+		spec, ok := allowedSessionParams[name]
+		if !ok {
+			return fmt.Errorf("session parameter %q is not whitelisted", name)
+		}
+		if !spec.valid.MatchString(value) {
+			return fmt.Errorf("invalid value %q for %s", value, name)
+		}
+		if _, err := tx.Exec(ctx, "SET LOCAL "+name+" = "+quoteLiteral(value)); err != nil {
+			return fmt.Errorf("set %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Command loadgen fires a configurable concurrency/duration workload at an
+// HTTP endpoint and reports latency percentiles and the error count. It is
+// the out-of-process counterpart to the /bench endpoint in work_mem/api,
+// for comparing the optimized and low work_mem demo routes without an
+// external tool like ab.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	target := flag.String("url", "http://localhost:8082/optimized-work-mem", "URL to load test")
+	concurrency := flag.Int("concurrency", 10, "number of concurrent workers")
+	duration := flag.Duration("duration", 5*time.Second, "how long to run the workload")
+	flag.Parse()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	deadline := time.Now().Add(*duration)
+
+	var (
+		mu      sync.Mutex
+		samples []time.Duration
+		errs    int64
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				start := time.Now()
+				resp, err := client.Get(*target)
+				elapsed := time.Since(start)
+				if err != nil {
+					atomic.AddInt64(&errs, 1)
+					continue
+				}
+				resp.Body.Close()
+				if resp.StatusCode >= 400 {
+					atomic.AddInt64(&errs, 1)
+					continue
+				}
+
+				mu.Lock()
+				samples = append(samples, elapsed)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	// samples holds only successful requests, so len(samples)+errs is the
+	// true request count and error-response latencies never reach the
+	// percentiles below.
+	fmt.Printf("url=%s concurrency=%d duration=%s requests=%d errors=%d\n",
+		*target, *concurrency, *duration, int64(len(samples))+errs, errs)
+	fmt.Printf("p50=%s p95=%s p99=%s\n",
+		percentile(samples, 0.50), percentile(samples, 0.95), percentile(samples, 0.99))
+
+	if errs > 0 {
+		os.Exit(1)
+	}
+}
+
+// percentile returns the p-th percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}